@@ -0,0 +1,124 @@
+package sgbucket
+
+import "encoding/json"
+
+// BulkGetter is implemented by buckets that can fetch several raw docs in one call. When
+// a Bucket passed to ProcessViewResult implements this, "include_docs" uses it instead of
+// fetching one row's doc at a time.
+type BulkGetter interface {
+	GetRawMulti(ids []string) (map[string][]byte, error)
+}
+
+// docCacheCapacity bounds the per-result-set doc cache used when the bucket doesn't
+// implement BulkGetter, so views with a huge number of distinct doc IDs don't hold every
+// fetched doc in memory at once.
+const docCacheCapacity = 256
+
+// fetchDocs populates each row's Doc field with its document body, for "include_docs".
+// It uses bucket's BulkGetter when available, batching the fetch and deduplicating IDs
+// that repeat across rows (e.g. a join-by-emit view that maps many rows to one doc).
+// Otherwise it falls back to bucket.GetRaw per row, backed by a small LRU so a repeated
+// ID within the result set doesn't refetch the same doc (each row still unmarshals its
+// own copy of the body).
+func fetchDocs(rows ViewRows, bucket Bucket) (ViewRows, error) {
+	if bulkGetter, ok := bucket.(BulkGetter); ok {
+		return fetchDocsBulk(rows, bulkGetter)
+	}
+	return fetchDocsOneByOne(rows, bucket.GetRaw)
+}
+
+// fetchDocsBulk is the fetchDocs path for buckets implementing BulkGetter: one
+// GetRawMulti call for every distinct doc ID referenced by rows.
+func fetchDocsBulk(rows ViewRows, bulkGetter BulkGetter) (ViewRows, error) {
+	raws, err := bulkGetter.GetRawMulti(uniqueRowIDs(rows))
+	if err != nil {
+		return nil, err
+	}
+	newRows := make(ViewRows, len(rows))
+	for i, row := range rows {
+		newRows[i] = row
+		var parsedDoc interface{}
+		if raw, found := raws[row.ID]; found {
+			json.Unmarshal(raw, &parsedDoc)
+		}
+		newRows[i].Doc = &parsedDoc
+	}
+	return newRows, nil
+}
+
+// fetchDocsOneByOne is the fetchDocs fallback path for buckets without BulkGetter: it
+// fetches getRaw(row.ID) per row, backed by a small LRU of raw bytes so a repeated ID
+// within the result set doesn't refetch the same doc; each row unmarshals its own copy.
+func fetchDocsOneByOne(rows ViewRows, getRaw func(id string) ([]byte, uint64, error)) (ViewRows, error) {
+	cache := newDocLRU(docCacheCapacity)
+	newRows := make(ViewRows, len(rows))
+	for i, row := range rows {
+		newRows[i] = row
+		raw, cached := cache.get(row.ID)
+		if !cached {
+			fetched, _, err := getRaw(row.ID)
+			if err != nil {
+				return nil, err
+			}
+			raw = fetched
+			cache.put(row.ID, raw)
+		}
+		var parsedDoc interface{}
+		json.Unmarshal(raw, &parsedDoc)
+		newRows[i].Doc = &parsedDoc
+	}
+	return newRows, nil
+}
+
+// uniqueRowIDs returns each row's ID, in first-seen order, with duplicates removed.
+func uniqueRowIDs(rows ViewRows) []string {
+	seen := make(map[string]bool, len(rows))
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if !seen[row.ID] {
+			seen[row.ID] = true
+			ids = append(ids, row.ID)
+		}
+	}
+	return ids
+}
+
+// docLRU is a small fixed-capacity LRU cache of raw doc bytes, keyed by doc ID.
+type docLRU struct {
+	capacity int
+	order    []string
+	entries  map[string][]byte
+}
+
+func newDocLRU(capacity int) *docLRU {
+	return &docLRU{capacity: capacity, entries: make(map[string][]byte, capacity)}
+}
+
+func (c *docLRU) get(id string) ([]byte, bool) {
+	raw, found := c.entries[id]
+	if found {
+		c.touch(id)
+	}
+	return raw, found
+}
+
+func (c *docLRU) put(id string, raw []byte) {
+	if _, exists := c.entries[id]; !exists && len(c.order) >= c.capacity {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		delete(c.entries, oldest)
+	}
+	c.entries[id] = raw
+	c.touch(id)
+}
+
+// touch moves id to the most-recently-used end of c.order, inserting it if new.
+func (c *docLRU) touch(id string) {
+	for i, existing := range c.order {
+		if existing == id {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.order = append(c.order, id)
+}
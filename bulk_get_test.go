@@ -0,0 +1,182 @@
+package sgbucket
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestUniqueRowIDsDedupsPreservingFirstSeenOrder(t *testing.T) {
+	rows := ViewRows{
+		viewRow(1.0, "a"),
+		viewRow(2.0, "b"),
+		viewRow(3.0, "a"),
+		viewRow(4.0, "c"),
+		viewRow(5.0, "b"),
+	}
+
+	ids := uniqueRowIDs(rows)
+	expected := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected %v, got %v", expected, ids)
+	}
+}
+
+func TestUniqueRowIDsEmpty(t *testing.T) {
+	if ids := uniqueRowIDs(nil); len(ids) != 0 {
+		t.Errorf("expected no ids, got %v", ids)
+	}
+}
+
+// fakeBulkGetter is a BulkGetter that records the IDs it was asked for, so tests can
+// assert fetchDocsBulk deduplicates before calling GetRawMulti.
+type fakeBulkGetter struct {
+	docs         map[string][]byte
+	requestedIDs []string
+}
+
+func (f *fakeBulkGetter) GetRawMulti(ids []string) (map[string][]byte, error) {
+	f.requestedIDs = ids
+	raws := make(map[string][]byte, len(ids))
+	for _, id := range ids {
+		if raw, found := f.docs[id]; found {
+			raws[id] = raw
+		}
+	}
+	return raws, nil
+}
+
+func TestFetchDocsBulkDedupesAndFillsMisses(t *testing.T) {
+	bulkGetter := &fakeBulkGetter{docs: map[string][]byte{
+		"doc1": []byte(`{"n":1}`),
+		"doc2": []byte(`{"n":2}`),
+	}}
+	rows := ViewRows{
+		viewRow(1.0, "doc1"),
+		viewRow(2.0, "doc2"),
+		viewRow(3.0, "doc1"),
+		viewRow(4.0, "missing"),
+	}
+
+	out, err := fetchDocsBulk(rows, bulkGetter)
+	if err != nil {
+		t.Fatalf("fetchDocsBulk failed: %v", err)
+	}
+
+	if expected := []string{"doc1", "doc2", "missing"}; !reflect.DeepEqual(bulkGetter.requestedIDs, expected) {
+		t.Errorf("expected GetRawMulti called with %v, got %v", expected, bulkGetter.requestedIDs)
+	}
+
+	if len(out) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(out))
+	}
+	if doc := (*out[0].Doc).(map[string]interface{}); doc["n"] != 1.0 {
+		t.Errorf("expected doc1's body, got %+v", out[0].Doc)
+	}
+	if out[3].Doc == nil || *out[3].Doc != nil {
+		t.Errorf("expected a missing doc to get a nil (JSON null) body, got %+v", out[3].Doc)
+	}
+}
+
+func TestFetchDocsBulkPropagatesError(t *testing.T) {
+	boom := errors.New("boom")
+	_, err := fetchDocsBulk(ViewRows{viewRow(1.0, "doc1")}, failingBulkGetter{err: boom})
+	if !errors.Is(err, boom) {
+		t.Errorf("expected %v, got %v", boom, err)
+	}
+}
+
+type failingBulkGetter struct{ err error }
+
+func (f failingBulkGetter) GetRawMulti(ids []string) (map[string][]byte, error) {
+	return nil, f.err
+}
+
+func TestFetchDocsOneByOneFetchesAndCachesRepeats(t *testing.T) {
+	fetchCount := map[string]int{}
+	getRaw := func(id string) ([]byte, uint64, error) {
+		fetchCount[id]++
+		return []byte(`{"id":"` + id + `"}`), 0, nil
+	}
+	rows := ViewRows{
+		viewRow(1.0, "doc1"),
+		viewRow(2.0, "doc2"),
+		viewRow(3.0, "doc1"),
+	}
+
+	out, err := fetchDocsOneByOne(rows, getRaw)
+	if err != nil {
+		t.Fatalf("fetchDocsOneByOne failed: %v", err)
+	}
+	if len(out) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(out))
+	}
+	if fetchCount["doc1"] != 1 {
+		t.Errorf("expected doc1 to be fetched once (cached on repeat), got %d fetches", fetchCount["doc1"])
+	}
+	if fetchCount["doc2"] != 1 {
+		t.Errorf("expected doc2 to be fetched once, got %d fetches", fetchCount["doc2"])
+	}
+}
+
+func TestFetchDocsOneByOnePropagatesError(t *testing.T) {
+	boom := errors.New("boom")
+	getRaw := func(id string) ([]byte, uint64, error) { return nil, 0, boom }
+
+	_, err := fetchDocsOneByOne(ViewRows{viewRow(1.0, "doc1")}, getRaw)
+	if !errors.Is(err, boom) {
+		t.Errorf("expected %v, got %v", boom, err)
+	}
+}
+
+func TestDocLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newDocLRU(2)
+	cache.put("a", []byte("a"))
+	cache.put("b", []byte("b"))
+	cache.put("c", []byte("c")) // capacity 2: evicts "a", the least recently used
+
+	if _, found := cache.get("a"); found {
+		t.Error("expected \"a\" to have been evicted")
+	}
+	if raw, found := cache.get("b"); !found || string(raw) != "b" {
+		t.Errorf("expected \"b\" to still be cached, found=%v raw=%q", found, raw)
+	}
+	if raw, found := cache.get("c"); !found || string(raw) != "c" {
+		t.Errorf("expected \"c\" to still be cached, found=%v raw=%q", found, raw)
+	}
+}
+
+func TestDocLRUTouchReordersOnGet(t *testing.T) {
+	cache := newDocLRU(2)
+	cache.put("a", []byte("a"))
+	cache.put("b", []byte("b"))
+
+	// Touching "a" via get moves it to most-recently-used, so the next put should
+	// evict "b" instead.
+	cache.get("a")
+	cache.put("c", []byte("c"))
+
+	if _, found := cache.get("b"); found {
+		t.Error("expected \"b\" to have been evicted after \"a\" was touched")
+	}
+	if _, found := cache.get("a"); !found {
+		t.Error("expected \"a\" to still be cached")
+	}
+	if _, found := cache.get("c"); !found {
+		t.Error("expected \"c\" to still be cached")
+	}
+}
+
+func TestDocLRUPutOverwriteDoesNotEvict(t *testing.T) {
+	cache := newDocLRU(2)
+	cache.put("a", []byte("a1"))
+	cache.put("b", []byte("b"))
+	cache.put("a", []byte("a2")) // re-putting an existing key must not count against capacity
+
+	if raw, found := cache.get("a"); !found || string(raw) != "a2" {
+		t.Errorf("expected updated value for \"a\", found=%v raw=%q", found, raw)
+	}
+	if _, found := cache.get("b"); !found {
+		t.Error("expected \"b\" to still be cached")
+	}
+}
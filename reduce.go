@@ -0,0 +1,213 @@
+package sgbucket
+
+import (
+	"fmt"
+
+	"github.com/dop251/goja"
+)
+
+// ReduceBatchSize is the number of input rows a ReduceFunction is given at once. When a
+// single group has more rows than this, they're reduced in batches and the partial
+// results are rereduced together (recursively, if there are still too many), mirroring
+// how CouchDB rereduces across B-tree nodes.
+var ReduceBatchSize = 100
+
+// ReduceFunction reduces a batch of view rows to a single row. If rereduce is true, the
+// rows passed in are themselves prior outputs of this same function, being combined
+// into a coarser result (see CouchDB's rereduce).
+type ReduceFunction func(rows []*ViewRow, rereduce bool) (*ViewRow, error)
+
+// ReduceFunc looks up a built-in reduce function by name ("_count", "_sum", "_stats")
+// or, for anything else, compiles reduceFunction as a JavaScript reduce function of the
+// form `function(keys, values, rereduce) { ... }`.
+func ReduceFunc(reduceFunction string) (ReduceFunction, error) {
+	switch reduceFunction {
+	case "_count":
+		return reduceCount, nil
+	case "_sum":
+		return reduceSum, nil
+	case "_stats":
+		return reduceStats, nil
+	default:
+		return compileJSReduceFunction(reduceFunction)
+	}
+}
+
+// reduceInBatches applies reduceFun to rows, splitting into ReduceBatchSize-sized
+// batches (and rereducing the partial results together) if there are too many rows to
+// reduce at once.
+func reduceInBatches(reduceFun ReduceFunction, rows []*ViewRow) (*ViewRow, error) {
+	if ReduceBatchSize <= 0 || len(rows) <= ReduceBatchSize {
+		return reduceFun(rows, false)
+	}
+	partials, err := reduceBatch(reduceFun, rows, false)
+	if err != nil {
+		return nil, err
+	}
+	for len(partials) > ReduceBatchSize {
+		partials, err = reduceBatch(reduceFun, partials, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return reduceFun(partials, true)
+}
+
+// reduceBatch splits rows into ReduceBatchSize-sized chunks and reduces each separately,
+// returning one output row per chunk.
+func reduceBatch(reduceFun ReduceFunction, rows []*ViewRow, rereduce bool) ([]*ViewRow, error) {
+	partials := make([]*ViewRow, 0, (len(rows)+ReduceBatchSize-1)/ReduceBatchSize)
+	for len(rows) > 0 {
+		n := ReduceBatchSize
+		if n > len(rows) {
+			n = len(rows)
+		}
+		partial, err := reduceFun(rows[:n], rereduce)
+		if err != nil {
+			return nil, err
+		}
+		partials = append(partials, partial)
+		rows = rows[n:]
+	}
+	return partials, nil
+}
+
+func reduceCount(rows []*ViewRow, rereduce bool) (*ViewRow, error) {
+	if !rereduce {
+		return &ViewRow{Value: float64(len(rows))}, nil
+	}
+	total := float64(0)
+	for _, row := range rows {
+		total += collationToFloat64(row.Value)
+	}
+	return &ViewRow{Value: total}, nil
+}
+
+func reduceSum(rows []*ViewRow, rereduce bool) (*ViewRow, error) {
+	total := float64(0)
+	for _, row := range rows {
+		// This could theoretically know how to unwrap our [channels, value]
+		// design_doc emit wrapper, but even so reduce would remain admin only.
+		total += collationToFloat64(row.Value)
+	}
+	return &ViewRow{Value: total}, nil
+}
+
+// statsResult is the value produced by the "_stats" reduce function.
+type statsResult struct {
+	Sum    float64 `json:"sum"`
+	Count  float64 `json:"count"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	SumSqr float64 `json:"sumsqr"`
+}
+
+func reduceStats(rows []*ViewRow, rereduce bool) (*ViewRow, error) {
+	var stats statsResult
+	first := true
+	for _, row := range rows {
+		if !rereduce {
+			v := collationToFloat64(row.Value)
+			stats.Sum += v
+			stats.Count++
+			stats.SumSqr += v * v
+			if first || v < stats.Min {
+				stats.Min = v
+			}
+			if first || v > stats.Max {
+				stats.Max = v
+			}
+		} else {
+			partial, err := asStatsResult(row.Value)
+			if err != nil {
+				return nil, err
+			}
+			stats.Sum += partial.Sum
+			stats.Count += partial.Count
+			stats.SumSqr += partial.SumSqr
+			if first || partial.Min < stats.Min {
+				stats.Min = partial.Min
+			}
+			if first || partial.Max > stats.Max {
+				stats.Max = partial.Max
+			}
+		}
+		first = false
+	}
+	return &ViewRow{Value: stats}, nil
+}
+
+// asStatsResult coerces a previously-produced "_stats" value (possibly round-tripped
+// through JSON as a map) back into a statsResult for rereducing.
+func asStatsResult(value interface{}) (statsResult, error) {
+	switch v := value.(type) {
+	case statsResult:
+		return v, nil
+	case map[string]interface{}:
+		return statsResult{
+			Sum:    collationToFloat64(v["sum"]),
+			Count:  collationToFloat64(v["count"]),
+			Min:    collationToFloat64(v["min"]),
+			Max:    collationToFloat64(v["max"]),
+			SumSqr: collationToFloat64(v["sumsqr"]),
+		}, nil
+	default:
+		return statsResult{}, fmt.Errorf("_stats rereduce expected a stats object, got %T", value)
+	}
+}
+
+// compileJSReduceFunction compiles source (a JS function literal) once, via goja, and
+// returns a ReduceFunction that invokes it as reduce(keys, values, rereduce). keys is an
+// array of [key, docid] pairs, or null when rereduce is true, matching CouchDB.
+func compileJSReduceFunction(source string) (ReduceFunction, error) {
+	vm := goja.New()
+	prog, err := goja.Compile("reduce", "("+source+")", true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reduce function: %w", err)
+	}
+	fnValue, err := vm.RunProgram(prog)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reduce function: %w", err)
+	}
+	fn, ok := goja.AssertFunction(fnValue)
+	if !ok {
+		return nil, fmt.Errorf("reduce function %q is not a JavaScript function", source)
+	}
+
+	return func(rows []*ViewRow, rereduce bool) (*ViewRow, error) {
+		var keysArg goja.Value
+		if rereduce {
+			keysArg = goja.Null()
+		} else {
+			keys := make([]interface{}, len(rows))
+			for i, row := range rows {
+				keys[i] = []interface{}{row.Key, row.ID}
+			}
+			keysArg = vm.ToValue(keys)
+		}
+		values := make([]interface{}, len(rows))
+		for i, row := range rows {
+			values[i] = row.Value
+		}
+
+		result, err := fn(goja.Undefined(), keysArg, vm.ToValue(values), vm.ToValue(rereduce))
+		if err != nil {
+			return nil, fmt.Errorf("reduce function failed: %w", err)
+		}
+		return &ViewRow{Value: exportReduceValue(result)}, nil
+	}, nil
+}
+
+// exportReduceValue converts a goja return value to the Go types the rest of this
+// package expects. goja's Export exports whole-number JS values as int64 and fractional
+// ones as float64, but every other numeric ViewRow.Value in this package is a float64
+// (what json.Unmarshal produces, and what collationToFloat64 assumes its input is), so a
+// JS reduce function returning a whole number would otherwise silently break callers
+// that expect float64.
+func exportReduceValue(value goja.Value) interface{} {
+	exported := value.Export()
+	if n, ok := exported.(int64); ok {
+		return float64(n)
+	}
+	return exported
+}
@@ -0,0 +1,74 @@
+package sgbucket
+
+import "testing"
+
+func TestReduceStats(t *testing.T) {
+	result := &ViewResult{Rows: ViewRows{
+		{Key: "a", Value: 1.0},
+		{Key: "a", Value: 2.0},
+		{Key: "a", Value: 3.0},
+	}}
+
+	if err := ReduceViewResult("_stats", ViewParams{Reduce: true}, result); err != nil {
+		t.Fatalf("reduce failed: %v", err)
+	}
+	if len(result.Rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(result.Rows))
+	}
+
+	stats, ok := result.Rows[0].Value.(statsResult)
+	if !ok {
+		t.Fatalf("expected statsResult, got %T", result.Rows[0].Value)
+	}
+	if stats.Sum != 6 || stats.Count != 3 || stats.Min != 1 || stats.Max != 3 || stats.SumSqr != 14 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestReduceStatsRereduce(t *testing.T) {
+	// Simulate two shards that each ran a "_stats" reduce, being rereduced together.
+	result := &ViewResult{Rows: ViewRows{
+		{Key: "a", Value: statsResult{Sum: 3, Count: 2, Min: 1, Max: 2, SumSqr: 5}},
+		{Key: "a", Value: statsResult{Sum: 12, Count: 2, Min: 5, Max: 7, SumSqr: 74}},
+	}}
+
+	reduceFun, err := ReduceFunc("_stats")
+	if err != nil {
+		t.Fatalf("ReduceFunc failed: %v", err)
+	}
+	row, err := reduceFun(result.Rows, true)
+	if err != nil {
+		t.Fatalf("rereduce failed: %v", err)
+	}
+	stats := row.Value.(statsResult)
+	if stats.Sum != 15 || stats.Count != 4 || stats.Min != 1 || stats.Max != 7 || stats.SumSqr != 79 {
+		t.Errorf("unexpected rereduced stats: %+v", stats)
+	}
+}
+
+func TestReduceJSFunctionRereduceBatching(t *testing.T) {
+	origBatchSize := ReduceBatchSize
+	ReduceBatchSize = 2
+	defer func() { ReduceBatchSize = origBatchSize }()
+
+	rows := make(ViewRows, 5)
+	for i := range rows {
+		rows[i] = &ViewRow{Key: "k", Value: float64(i + 1)}
+	}
+	result := &ViewResult{Rows: rows}
+
+	jsSum := `function(keys, values, rereduce) {
+		var total = 0;
+		for (var i = 0; i < values.length; i++) { total += values[i]; }
+		return total;
+	}`
+	if err := ReduceViewResult(jsSum, ViewParams{Reduce: true}, result); err != nil {
+		t.Fatalf("reduce failed: %v", err)
+	}
+	if len(result.Rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(result.Rows))
+	}
+	if sum := collationToFloat64(result.Rows[0].Value); sum != 15 {
+		t.Errorf("expected sum 15 (batched through ReduceBatchSize=2), got %v", sum)
+	}
+}
@@ -0,0 +1,136 @@
+package sgbucket
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+// StreamingViewResult is like ViewResult, but delivers its rows over a channel instead
+// of holding them all in memory, so view output from multiple buckets/collections can be
+// composed without an O(N) memory blowup. Rows must arrive in ascending key (then doc
+// ID) order, same as ViewResult.Rows.
+type StreamingViewResult struct {
+	Rows      <-chan *ViewRow
+	TotalRows int
+}
+
+// mergeSource tracks the next unread row from one of MergeViewResults' input channels.
+type mergeSource struct {
+	ch  <-chan *ViewRow
+	row *ViewRow
+}
+
+// mergeHeap is a min-heap of mergeSources, ordered by the pending row's key (and doc ID,
+// as a tiebreaker), via JSONCollator.
+type mergeHeap struct {
+	sources  []*mergeSource
+	collator *JSONCollator
+}
+
+func (h *mergeHeap) Len() int { return len(h.sources) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	a, b := h.sources[i].row, h.sources[j].row
+	if cmp := h.collator.Collate(a.Key, b.Key); cmp != 0 {
+		return cmp < 0
+	}
+	return a.ID < b.ID
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.sources[i], h.sources[j] = h.sources[j], h.sources[i] }
+
+func (h *mergeHeap) Push(x interface{}) { h.sources = append(h.sources, x.(*mergeSource)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	old := h.sources
+	n := len(old)
+	source := old[n-1]
+	h.sources = old[:n-1]
+	return source
+}
+
+// MergeViewResults performs an n-way merge of pre-sorted view row channels (each already
+// ascending by key/doc ID, e.g. the Rows of a ViewResult streamed from a shard) into a
+// single ascending stream written to out. It closes out once every input is drained.
+func MergeViewResults(inputs []<-chan *ViewRow, out chan<- *ViewRow) {
+	defer close(out)
+
+	var collator JSONCollator
+	h := &mergeHeap{collator: &collator}
+	for _, in := range inputs {
+		if row, ok := <-in; ok {
+			h.sources = append(h.sources, &mergeSource{ch: in, row: row})
+		}
+	}
+	heap.Init(h)
+
+	for h.Len() > 0 {
+		source := heap.Pop(h).(*mergeSource)
+		out <- source.row
+		if row, ok := <-source.ch; ok {
+			source.row = row
+			heap.Push(h, source)
+		}
+	}
+}
+
+// ProcessViewResultStreaming is the streaming counterpart of ProcessViewResult: it
+// consumes rows (typically the output of MergeViewResults) and applies
+// startkey/endkey/skip/limit/reduce without ever materializing the full upstream result
+// set in memory — only the rows that fall within the requested key range are collected.
+//
+// "descending" isn't supported here yet, since it would require buffering the whole
+// stream; callers needing descending order should use ProcessViewResult on a
+// materialized result.
+func ProcessViewResultStreaming(rows <-chan *ViewRow, params ViewParams,
+	bucket Bucket, reduceFunction string) (ViewResult, error) {
+	if params.Descending {
+		drainViewRows(rows)
+		return ViewResult{}, fmt.Errorf("ProcessViewResultStreaming does not support descending yet, sorry")
+	}
+
+	var collator JSONCollator
+	var matched ViewRows
+	skipped := 0
+	stoppedEarly := false
+	for row := range rows {
+		if params.StartKey != nil && compareRowToBound(&collator, row, params.StartKey, params.StartKeyDocID) < 0 {
+			continue
+		}
+		if params.EndKey != nil {
+			if params.InclusiveEnd {
+				if compareRowToBound(&collator, row, params.EndKey, params.EndKeyDocID) > 0 {
+					stoppedEarly = true
+					break // rows arrive in ascending order, so nothing further can match
+				}
+			} else if collator.Collate(row.Key, params.EndKey) >= 0 {
+				stoppedEarly = true
+				break
+			}
+		}
+		if skipped < params.Skip {
+			skipped++
+			continue
+		}
+		matched = append(matched, row)
+		if params.Limit > 0 && len(matched) >= params.Limit {
+			stoppedEarly = true
+			break
+		}
+	}
+	if stoppedEarly {
+		drainViewRows(rows)
+	}
+
+	result := ViewResult{Rows: matched}
+	return finishViewResult(result, params, bucket, reduceFunction)
+}
+
+// drainViewRows discards the remainder of rows in the background, so that a producer
+// (like MergeViewResults) blocked sending on it isn't leaked when a consumer stops early.
+func drainViewRows(rows <-chan *ViewRow) {
+	go func() {
+		for range rows {
+		}
+	}()
+}
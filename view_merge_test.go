@@ -0,0 +1,186 @@
+package sgbucket
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// rowChannel returns a channel that delivers rows (which must already be in ascending
+// key/ID order) and then closes, mimicking a single shard's streamed view output.
+func rowChannel(rows ...*ViewRow) <-chan *ViewRow {
+	ch := make(chan *ViewRow)
+	go func() {
+		defer close(ch)
+		for _, row := range rows {
+			ch <- row
+		}
+	}()
+	return ch
+}
+
+func drainToSlice(ch <-chan *ViewRow) ViewRows {
+	var rows ViewRows
+	for row := range ch {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestMergeViewResultsInterleavesAndBreaksTiesByDocID(t *testing.T) {
+	a := rowChannel(viewRow(1.0, "a1"), viewRow(3.0, "a3"), viewRow(5.0, "a5"))
+	b := rowChannel(viewRow(2.0, "b2"), viewRow(3.0, "b3"), viewRow(4.0, "b4"))
+
+	out := make(chan *ViewRow)
+	go MergeViewResults([]<-chan *ViewRow{a, b}, out)
+
+	var got []string
+	for row := range out {
+		got = append(got, fmt.Sprintf("%v:%s", row.Key, row.ID))
+	}
+
+	expected := []string{"1:a1", "2:b2", "3:a3", "3:b3", "4:b4", "5:a5"}
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, got)
+			break
+		}
+	}
+}
+
+func TestMergeViewResultsSingleInput(t *testing.T) {
+	a := rowChannel(viewRow(1.0, "a1"), viewRow(2.0, "a2"))
+	out := make(chan *ViewRow)
+	go MergeViewResults([]<-chan *ViewRow{a}, out)
+
+	rows := drainToSlice(out)
+	if len(rows) != 2 || rows[0].ID != "a1" || rows[1].ID != "a2" {
+		t.Errorf("expected [a1 a2], got %+v", rows)
+	}
+}
+
+func TestMergeViewResultsNoInputs(t *testing.T) {
+	out := make(chan *ViewRow)
+	go MergeViewResults(nil, out)
+
+	if rows := drainToSlice(out); len(rows) != 0 {
+		t.Errorf("expected no rows, got %+v", rows)
+	}
+}
+
+func TestMergeViewResultsSkipsEmptyChannel(t *testing.T) {
+	a := rowChannel(viewRow(1.0, "a1"))
+	empty := rowChannel()
+	out := make(chan *ViewRow)
+	go MergeViewResults([]<-chan *ViewRow{a, empty}, out)
+
+	rows := drainToSlice(out)
+	if len(rows) != 1 || rows[0].ID != "a1" {
+		t.Errorf("expected [a1], got %+v", rows)
+	}
+}
+
+func TestProcessViewResultStreamingKeyRange(t *testing.T) {
+	ch := rowChannel(
+		viewRow(1.0, "d1"), viewRow(2.0, "d2"), viewRow(3.0, "d3"),
+		viewRow(4.0, "d4"), viewRow(5.0, "d5"),
+	)
+	params := ViewParams{StartKey: 2.0, EndKey: 4.0, InclusiveEnd: true, Reduce: true}
+
+	result, err := ProcessViewResultStreaming(ch, params, nil, "")
+	if err != nil {
+		t.Fatalf("ProcessViewResultStreaming failed: %v", err)
+	}
+	if len(result.Rows) != 3 || result.Rows[0].ID != "d2" || result.Rows[2].ID != "d4" {
+		t.Errorf("expected [d2 d3 d4], got %+v", result.Rows)
+	}
+}
+
+func TestProcessViewResultStreamingExclusiveEndStopsEarly(t *testing.T) {
+	ch := rowChannel(
+		viewRow(1.0, "d1"), viewRow(2.0, "d2"), viewRow(3.0, "d3"), viewRow(4.0, "d4"),
+	)
+	params := ViewParams{StartKey: 1.0, EndKey: 3.0, InclusiveEnd: false, Reduce: true}
+
+	result, err := ProcessViewResultStreaming(ch, params, nil, "")
+	if err != nil {
+		t.Fatalf("ProcessViewResultStreaming failed: %v", err)
+	}
+	if len(result.Rows) != 2 || result.Rows[0].ID != "d1" || result.Rows[1].ID != "d2" {
+		t.Errorf("expected [d1 d2], got %+v", result.Rows)
+	}
+}
+
+func TestProcessViewResultStreamingSkipAndLimit(t *testing.T) {
+	ch := rowChannel(
+		viewRow(1.0, "d1"), viewRow(2.0, "d2"), viewRow(3.0, "d3"),
+		viewRow(4.0, "d4"), viewRow(5.0, "d5"),
+	)
+	params := ViewParams{Skip: 1, Limit: 2, Reduce: true}
+
+	result, err := ProcessViewResultStreaming(ch, params, nil, "")
+	if err != nil {
+		t.Fatalf("ProcessViewResultStreaming failed: %v", err)
+	}
+	if len(result.Rows) != 2 || result.Rows[0].ID != "d2" || result.Rows[1].ID != "d3" {
+		t.Errorf("expected [d2 d3], got %+v", result.Rows)
+	}
+}
+
+// TestProcessViewResultStreamingLimitDrainsRemainder verifies that cutting off at limit
+// doesn't deadlock the upstream sender: it must drain (and discard) whatever's left on
+// the channel instead of leaving the producer goroutine blocked forever.
+func TestProcessViewResultStreamingLimitDrainsRemainder(t *testing.T) {
+	rows := make([]*ViewRow, 20)
+	for i := range rows {
+		rows[i] = viewRow(float64(i+1), fmt.Sprintf("d%d", i+1))
+	}
+	ch := make(chan *ViewRow)
+	producerDone := make(chan struct{})
+	go func() {
+		defer close(producerDone)
+		defer close(ch)
+		for _, row := range rows {
+			ch <- row
+		}
+	}()
+	params := ViewParams{Limit: 3, Reduce: true}
+
+	done := make(chan struct{})
+	var result ViewResult
+	var err error
+	go func() {
+		result, err = ProcessViewResultStreaming(ch, params, nil, "")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("ProcessViewResultStreaming did not return — likely deadlocked on the producer")
+	}
+	if err != nil {
+		t.Fatalf("ProcessViewResultStreaming failed: %v", err)
+	}
+	if len(result.Rows) != 3 {
+		t.Errorf("expected 3 rows, got %d", len(result.Rows))
+	}
+
+	select {
+	case <-producerDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("producer still blocked: the rows after the limit were not drained")
+	}
+}
+
+func TestProcessViewResultStreamingDescendingUnsupported(t *testing.T) {
+	ch := rowChannel(viewRow(1.0, "d1"))
+	params := ViewParams{Descending: true}
+
+	if _, err := ProcessViewResultStreaming(ch, params, nil, ""); err == nil {
+		t.Error("expected an error for descending, got nil")
+	}
+}
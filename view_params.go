@@ -0,0 +1,86 @@
+package sgbucket
+
+// ViewParams holds the query parameters accepted by ProcessViewResult and
+// ReduceViewResult, equivalent to CouchDB's view query string parameters. Zero-value
+// ViewParams is not a valid "no filtering" value for InclusiveEnd/Reduce, which default
+// to true in CouchDB — construct one via ViewParamsFromMap, or set those fields
+// explicitly.
+type ViewParams struct {
+	StartKey      interface{}
+	StartKeyDocID string
+	EndKey        interface{}
+	EndKeyDocID   string
+	InclusiveEnd  bool
+	Key           interface{}
+	Keys          []interface{}
+	Limit         int
+	Skip          int
+	Descending    bool
+	Reduce        bool
+	Group         bool
+	GroupLevel    int
+	IncludeDocs   bool
+	Stale         string
+	UpdateSeq     bool
+}
+
+// ViewParamsFromMap builds a ViewParams from the loosely-typed map this package used to
+// accept directly, for callers that still parse query strings or JSON bodies into a
+// map[string]interface{}. InclusiveEnd and Reduce default to true, matching CouchDB.
+func ViewParamsFromMap(params map[string]interface{}) ViewParams {
+	p := ViewParams{InclusiveEnd: true, Reduce: true}
+	if params == nil {
+		return p
+	}
+
+	p.StartKey = params["startkey"]
+	if p.StartKey == nil {
+		p.StartKey = params["start_key"] // older synonym
+	}
+	p.EndKey = params["endkey"]
+	if p.EndKey == nil {
+		p.EndKey = params["end_key"]
+	}
+	p.StartKeyDocID, _ = params["startkey_docid"].(string)
+	p.EndKeyDocID, _ = params["endkey_docid"].(string)
+
+	if key, found := params["key"]; found && key != nil {
+		p.Key = key
+		p.StartKey = key
+		p.EndKey = key
+	}
+	if keys, ok := params["keys"].([]interface{}); ok {
+		p.Keys = keys
+	}
+
+	if value, ok := params["inclusive_end"].(bool); ok {
+		p.InclusiveEnd = value
+	}
+	if value, ok := params["limit"].(uint64); ok {
+		p.Limit = int(value)
+	}
+	if value, ok := params["skip"].(uint64); ok {
+		p.Skip = int(value)
+	}
+
+	// "reverse" is this package's historical name for CouchDB's "descending".
+	p.Descending, _ = params["reverse"].(bool)
+	if value, ok := params["descending"].(bool); ok {
+		p.Descending = value
+	}
+
+	if value, ok := params["reduce"].(bool); ok {
+		p.Reduce = value
+	}
+	if value, ok := params["group"].(bool); ok {
+		p.Group = value
+	}
+	if value, ok := params["group_level"].(uint64); ok {
+		p.GroupLevel = int(value)
+	}
+	p.IncludeDocs, _ = params["include_docs"].(bool)
+	p.Stale, _ = params["stale"].(string)
+	p.UpdateSeq, _ = params["update_seq"].(bool)
+
+	return p
+}
@@ -0,0 +1,57 @@
+package sgbucket
+
+import "testing"
+
+func TestProcessViewResultKeysWithGroupNoMatches(t *testing.T) {
+	rows := ViewRows{
+		viewRow([]interface{}{"a", 1.0}, "doc1"),
+		viewRow([]interface{}{"b", 1.0}, "doc2"),
+	}
+	result := ViewResult{Rows: rows}
+	params := ViewParams{
+		Keys:       []interface{}{"nonexistent"},
+		GroupLevel: 1,
+		Reduce:     true,
+	}
+
+	// None of the requested keys match any row, so this must come back empty rather
+	// than panic indexing result.Rows[0] inside ReduceViewResult's grouping.
+	out, err := ProcessViewResult(result, params, nil, "_count")
+	if err != nil {
+		t.Fatalf("ProcessViewResult failed: %v", err)
+	}
+	if len(out.Rows) != 0 {
+		t.Errorf("expected no rows when no keys match, got %+v", out.Rows)
+	}
+}
+
+func TestProcessViewResultKeysWithGroup(t *testing.T) {
+	rows := ViewRows{
+		viewRow([]interface{}{"a", 1.0}, "doc1"),
+		viewRow([]interface{}{"a", 2.0}, "doc2"),
+		viewRow([]interface{}{"b", 1.0}, "doc3"),
+		viewRow([]interface{}{"c", 1.0}, "doc4"),
+	}
+	result := ViewResult{Rows: rows}
+	params := ViewParams{
+		Keys: []interface{}{
+			[]interface{}{"a", 1.0},
+			[]interface{}{"c", 1.0},
+		},
+		GroupLevel: 1,
+		Reduce:     true,
+	}
+
+	out, err := ProcessViewResult(result, params, nil, "_count")
+	if err != nil {
+		t.Fatalf("ProcessViewResult failed: %v", err)
+	}
+	if len(out.Rows) != 2 {
+		t.Fatalf("expected 2 groups, got %d: %+v", len(out.Rows), out.Rows)
+	}
+	for _, row := range out.Rows {
+		if collationToFloat64(row.Value) != 1 {
+			t.Errorf("expected each group to have count 1, got %+v", row)
+		}
+	}
+}
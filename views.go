@@ -27,89 +27,118 @@ func CheckDDoc(value interface{}) (*DesignDoc, error) {
 }
 
 // Applies view params (startkey/endkey, limit, etc) against a ViewResult.
-func ProcessViewResult(result ViewResult, params map[string]interface{},
+func ProcessViewResult(result ViewResult, params ViewParams,
 	bucket Bucket, reduceFunction string) (ViewResult, error) {
-	includeDocs := false
-	limit := 0
-	reverse := false
-	reduce := true
-
-	if params != nil {
-		includeDocs, _ = params["include_docs"].(bool)
-		plimit, ok := params["limit"].(uint64)
-		if ok {
-			limit = int(plimit)
-		}
-		reverse, _ = params["reverse"].(bool)
-		if reduceParam, found := params["reduce"].(bool); found {
-			reduce = reduceParam
-		}
-	}
+	var collator JSONCollator
 
-	if reverse {
-		//TODO: Apply "reverse" option
-		return result, fmt.Errorf("Reverse is not supported yet, sorry")
+	if len(params.Keys) > 0 {
+		result.Rows = filterRowsByKeys(result.Rows, &collator, params.Keys, params.Reduce)
 	}
 
-	startkey := params["startkey"]
-	if startkey == nil {
-		startkey = params["start_key"] // older synonym
+	// result.Rows is always sorted ascending by key (see ViewResult.Less), regardless
+	// of "descending". When descending, startkey/endkey swap roles: startkey becomes the
+	// upper bound and endkey becomes the lower bound, same as CouchDB's descending=true.
+	// InclusiveEnd always governs whichever bound came from endkey, not whichever side
+	// of the (possibly swapped) array it ends up filtering.
+	lowerBound, lowerDocID, lowerInclusive := params.StartKey, params.StartKeyDocID, true
+	upperBound, upperDocID, upperInclusive := params.EndKey, params.EndKeyDocID, params.InclusiveEnd
+	if params.Descending {
+		lowerBound, upperBound = upperBound, lowerBound
+		lowerDocID, upperDocID = upperDocID, lowerDocID
+		lowerInclusive, upperInclusive = upperInclusive, lowerInclusive
 	}
-	endkey := params["endkey"]
-	if endkey == nil {
-		endkey = params["end_key"]
+
+	if lowerBound != nil {
+		var i int
+		if lowerInclusive {
+			i = sort.Search(len(result.Rows), func(i int) bool {
+				return compareRowToBound(&collator, result.Rows[i], lowerBound, lowerDocID) >= 0
+			})
+		} else {
+			i = sort.Search(len(result.Rows), func(i int) bool {
+				return collator.Collate(result.Rows[i].Key, lowerBound) > 0
+			})
+		}
+		result.Rows = result.Rows[i:]
 	}
-	inclusiveEnd := true
-	if key := params["key"]; key != nil {
-		startkey = key
-		endkey = key
-	} else {
-		if value, ok := params["inclusive_end"].(bool); ok {
-			inclusiveEnd = value
+
+	if upperBound != nil {
+		var i int
+		if upperInclusive {
+			i = sort.Search(len(result.Rows), func(i int) bool {
+				return compareRowToBound(&collator, result.Rows[i], upperBound, upperDocID) > 0
+			})
+		} else {
+			i = sort.Search(len(result.Rows), func(i int) bool {
+				return collator.Collate(result.Rows[i].Key, upperBound) >= 0
+			})
 		}
+		result.Rows = result.Rows[:i]
 	}
 
-	var collator JSONCollator
+	if params.Descending {
+		reverseRows(result.Rows)
+	}
 
-	if startkey != nil {
-		i := sort.Search(len(result.Rows), func(i int) bool {
-			return collator.Collate(result.Rows[i].Key, startkey) >= 0
-		})
-		result.Rows = result.Rows[i:]
+	if params.Skip > 0 {
+		if params.Skip >= len(result.Rows) {
+			result.Rows = result.Rows[:0]
+		} else {
+			result.Rows = result.Rows[params.Skip:]
+		}
 	}
 
-	if limit > 0 && len(result.Rows) > limit {
-		result.Rows = result.Rows[:limit]
+	if params.Limit > 0 && len(result.Rows) > params.Limit {
+		result.Rows = result.Rows[:params.Limit]
 	}
 
-	if endkey != nil {
-		limit := 0
-		if !inclusiveEnd {
-			limit = -1
+	return finishViewResult(result, params, bucket, reduceFunction)
+}
+
+// filterRowsByKeys narrows rows down to those whose Key matches one of keys (the "keys"
+// query parameter). When reduce is true, rows stay in their original ascending order,
+// since ReduceViewResult's grouping relies on that; otherwise they come back grouped in
+// the order the keys were requested, matching CouchDB.
+func filterRowsByKeys(rows ViewRows, collator *JSONCollator, keys []interface{}, reduce bool) ViewRows {
+	if reduce {
+		kept := make(ViewRows, 0, len(rows))
+		for _, row := range rows {
+			for _, key := range keys {
+				if collator.Collate(row.Key, key) == 0 {
+					kept = append(kept, row)
+					break
+				}
+			}
 		}
-		i := sort.Search(len(result.Rows), func(i int) bool {
-			return collator.Collate(result.Rows[i].Key, endkey) > limit
+		return kept
+	}
+
+	ordered := make(ViewRows, 0, len(rows))
+	for _, key := range keys {
+		i := sort.Search(len(rows), func(i int) bool {
+			return collator.Collate(rows[i].Key, key) >= 0
 		})
-		result.Rows = result.Rows[:i]
+		for i < len(rows) && collator.Collate(rows[i].Key, key) == 0 {
+			ordered = append(ordered, rows[i])
+			i++
+		}
 	}
+	return ordered
+}
 
-	if includeDocs {
-		newRows := make(ViewRows, len(result.Rows))
-		for i, row := range result.Rows {
-			//OPT: This may unmarshal the same doc more than once
-			raw, _, err := bucket.GetRaw(row.ID)
-			if err != nil {
-				return result, err
-			}
-			var parsedDoc interface{}
-			json.Unmarshal(raw, &parsedDoc)
-			newRows[i] = row
-			newRows[i].Doc = &parsedDoc
+// finishViewResult applies include_docs and reduce to an already key/limit-filtered set
+// of rows, and fills in TotalRows. Shared by ProcessViewResult and its streaming variant.
+func finishViewResult(result ViewResult, params ViewParams, bucket Bucket,
+	reduceFunction string) (ViewResult, error) {
+	if params.IncludeDocs {
+		newRows, err := fetchDocs(result.Rows, bucket)
+		if err != nil {
+			return result, err
 		}
 		result.Rows = newRows
 	}
 
-	if reduce && reduceFunction != "" {
+	if params.Reduce && reduceFunction != "" {
 		if err := ReduceViewResult(reduceFunction, params, &result); err != nil {
 			return result, err
 		}
@@ -120,18 +149,25 @@ func ProcessViewResult(result ViewResult, params map[string]interface{},
 	return result, nil
 }
 
-func ReduceViewResult(reduceFunction string, params map[string]interface{}, result *ViewResult) error {
+func ReduceViewResult(reduceFunction string, params ViewParams, result *ViewResult) error {
 	reduceFun, compileErr := ReduceFunc(reduceFunction)
 	if compileErr != nil {
 		return compileErr
 	}
 	groupLevel := 0
-	if params["group"] != nil && params["group"].(bool) == true {
+	if params.Group {
 		groupLevel = -1
-	} else if params["group_level"] != nil {
-		groupLevel = int(params["group_level"].(uint64))
+	} else if params.GroupLevel != 0 {
+		groupLevel = params.GroupLevel
 	}
 	if groupLevel != 0 {
+		if len(result.Rows) == 0 {
+			// No rows means no groups (e.g. a "keys" query whose keys all missed) —
+			// nothing to index into below, and there's no group key to attach a
+			// zero-value row to, so the reduced result is simply empty.
+			result.Rows = ViewRows{}
+			return nil
+		}
 		var collator JSONCollator
 		key := result.Rows[0].Key
 		if groupLevel != -1 {
@@ -150,7 +186,7 @@ func ReduceViewResult(reduceFunction string, params map[string]interface{}, resu
 			if collated == 0 {
 				inRows = append(inRows, row)
 			} else {
-				outRow, outErr := reduceFun(inRows)
+				outRow, outErr := reduceInBatches(reduceFun, inRows)
 				if outErr != nil {
 					return outErr
 				}
@@ -162,14 +198,14 @@ func ReduceViewResult(reduceFunction string, params map[string]interface{}, resu
 			}
 		}
 		// do last key
-		outRow, outErr := reduceFun(inRows)
+		outRow, outErr := reduceInBatches(reduceFun, inRows)
 		if outErr != nil {
 			return outErr
 		}
 		outRow.Key = key
 		result.Rows = append(outRows, outRow)
 	} else {
-		row, err := reduceFun(result.Rows)
+		row, err := reduceInBatches(reduceFun, result.Rows)
 		if err != nil {
 			return err
 		}
@@ -182,25 +218,30 @@ func keyPrefix(groupLevel int, key interface{}) []interface{} {
 	return key.([]interface{})[0:groupLevel]
 }
 
-func ReduceFunc(reduceFunction string) (func([]*ViewRow) (*ViewRow, error), error) {
-	switch reduceFunction {
-	case "_count":
-		return func(rows []*ViewRow) (*ViewRow, error) {
-			return &ViewRow{Value: float64(len(rows))}, nil
-		}, nil
-	case "_sum":
-		return func(rows []*ViewRow) (*ViewRow, error) {
-			total := float64(0)
-			for _, row := range rows {
-				// This could theoretically know how to unwrap our [channels, value]
-				// design_doc emit wrapper, but even so reduce would remain admin only.
-				total += collationToFloat64(row.Value)
-			}
-			return &ViewRow{Value: total}, nil
-		}, nil
+// compareRowToBound compares a row's key (and, if boundDocID is non-empty, its doc ID
+// as a tie-breaker) against a startkey/endkey bound. Used to locate stable cut points
+// for startkey_docid/endkey_docid when several rows share an identical key.
+func compareRowToBound(collator *JSONCollator, row *ViewRow, boundKey interface{}, boundDocID string) int {
+	if cmp := collator.Collate(row.Key, boundKey); cmp != 0 {
+		return cmp
+	}
+	if boundDocID == "" {
+		return 0
+	}
+	switch {
+	case row.ID < boundDocID:
+		return -1
+	case row.ID > boundDocID:
+		return 1
 	default:
-		// TODO: Implement other reduce functions!
-		return nil, fmt.Errorf("Sgbucket only supports _count and _sum reduce functions")
+		return 0
+	}
+}
+
+// reverseRows reverses a slice of view rows in place, to implement "reverse"/"descending".
+func reverseRows(rows ViewRows) {
+	for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+		rows[i], rows[j] = rows[j], rows[i]
 	}
 }
 
@@ -0,0 +1,89 @@
+package sgbucket
+
+import "testing"
+
+func viewRow(key interface{}, id string) *ViewRow {
+	return &ViewRow{Key: key, ID: id}
+}
+
+func rowKeys(rows ViewRows) []interface{} {
+	keys := make([]interface{}, len(rows))
+	for i, row := range rows {
+		keys[i] = row.Key
+	}
+	return keys
+}
+
+func TestProcessViewResultDescendingInclusiveEnd(t *testing.T) {
+	rows := ViewRows{
+		viewRow(1.0, "doc1"),
+		viewRow(2.0, "doc2"),
+		viewRow(3.0, "doc3"),
+		viewRow(4.0, "doc4"),
+		viewRow(5.0, "doc5"),
+	}
+
+	run := func(inclusiveEnd bool) ViewRows {
+		result := ViewResult{Rows: append(ViewRows{}, rows...)}
+		params := ViewParams{
+			StartKey:     4.0,
+			EndKey:       2.0,
+			InclusiveEnd: inclusiveEnd,
+			Descending:   true,
+			Reduce:       true,
+		}
+		out, err := ProcessViewResult(result, params, nil, "")
+		if err != nil {
+			t.Fatalf("ProcessViewResult failed: %v", err)
+		}
+		return out.Rows
+	}
+
+	// startkey (4) is always inclusive, regardless of direction; endkey (2) is governed
+	// by inclusive_end, even though descending makes it the *lower* bound here.
+	if keys := rowKeys(run(false)); len(keys) != 2 || keys[0] != 4.0 || keys[1] != 3.0 {
+		t.Errorf("inclusive_end=false: expected [4 3], got %v", keys)
+	}
+	if keys := rowKeys(run(true)); len(keys) != 3 || keys[0] != 4.0 || keys[1] != 3.0 || keys[2] != 2.0 {
+		t.Errorf("inclusive_end=true: expected [4 3 2], got %v", keys)
+	}
+}
+
+func TestProcessViewResultStartEndKeyDocIDTieBreak(t *testing.T) {
+	rows := ViewRows{
+		viewRow(1.0, "a"),
+		viewRow(1.0, "b"),
+		viewRow(1.0, "c"),
+		viewRow(2.0, "a"),
+		viewRow(2.0, "b"),
+	}
+	result := ViewResult{Rows: rows}
+	params := ViewParams{
+		StartKey:      1.0,
+		StartKeyDocID: "b",
+		EndKey:        2.0,
+		EndKeyDocID:   "a",
+		InclusiveEnd:  true,
+		Reduce:        true,
+	}
+
+	out, err := ProcessViewResult(result, params, nil, "")
+	if err != nil {
+		t.Fatalf("ProcessViewResult failed: %v", err)
+	}
+	var ids []string
+	for _, row := range out.Rows {
+		ids = append(ids, row.ID)
+	}
+	// startkey_docid=b drops row (1,a); endkey_docid=a keeps (2,a) but not (2,b).
+	expected := []string{"b", "c", "a"}
+	if len(ids) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, ids)
+	}
+	for i := range expected {
+		if ids[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, ids)
+			break
+		}
+	}
+}